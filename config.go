@@ -0,0 +1,117 @@
+package errcheckif
+
+import (
+	"go/ast"
+	"go/types"
+	"regexp"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+// 下面这些包级变量由 Analyzer.Flags 填充，供 run 中的各个 pass 读取。
+var (
+	excludedFunctions   = map[string]bool{}
+	excludeFilesPattern *regexp.Regexp
+	checkBlank          bool
+	checkTypeAssertions bool
+)
+
+func init() {
+	Analyzer.Flags.Var(funcListFlag{excludedFunctions}, "exclude-functions",
+		"comma-separated list of function full names (e.g. fmt.Println,fmt.Fprintf,(*bytes.Buffer).Write) "+
+			"whose returned errors are conventionally ignored and should not be reported")
+	Analyzer.Flags.Var(regexpFlag{&excludeFilesPattern}, "exclude-files",
+		"regexp matching generated/vendored file paths to skip, in addition to _test.go")
+	Analyzer.Flags.BoolVar(&checkBlank, "check-blank", true,
+		"report `_, _ = fn()` style ignored errors")
+	Analyzer.Flags.BoolVar(&checkTypeAssertions, "check-type-assertions", false,
+		"also report `x, _ := i.(T)` style ignored type-assertion results")
+}
+
+// funcListFlag 将逗号分隔的函数全名列表解析进一个 map，便于 O(1) 查找。
+type funcListFlag struct {
+	set map[string]bool
+}
+
+func (f funcListFlag) String() string { return "" }
+
+func (f funcListFlag) Set(s string) error {
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			f.set[name] = true
+		}
+	}
+	return nil
+}
+
+// regexpFlag 编译传入的正则表达式并存入目标指针。
+type regexpFlag struct {
+	dst **regexp.Regexp
+}
+
+func (f regexpFlag) String() string { return "" }
+
+func (f regexpFlag) Set(s string) error {
+	re, err := regexp.Compile(s)
+	if err != nil {
+		return err
+	}
+	*f.dst = re
+	return nil
+}
+
+// isExcludedFile 判断一个文件是否应被跳过：_test.go 文件始终跳过，
+// 此外还遵循 -exclude-files 指定的正则（通常用来跳过生成的代码）。
+func isExcludedFile(name string) bool {
+	if strings.HasSuffix(name, "_test.go") {
+		return true
+	}
+	return excludeFilesPattern != nil && excludeFilesPattern.MatchString(name)
+}
+
+// isExcludedFunctionCall 判断一次调用的被调函数是否出现在 -exclude-functions 列表中，
+// 列表以 types.Func.FullName() 为键，因此同时支持包级函数和方法。
+func isExcludedFunctionCall(pass *analysis.Pass, call *ast.CallExpr) bool {
+	if len(excludedFunctions) == 0 {
+		return false
+	}
+
+	var fn *types.Func
+	switch f := call.Fun.(type) {
+	case *ast.Ident:
+		fn, _ = pass.TypesInfo.Uses[f].(*types.Func)
+	case *ast.SelectorExpr:
+		fn, _ = pass.TypesInfo.Uses[f.Sel].(*types.Func)
+	}
+	if fn == nil {
+		return false
+	}
+	return excludedFunctions[fn.FullName()]
+}
+
+// runTypeAssertionCheck 是可选的 P4 pass：当 -check-type-assertions 开启时，
+// 检查 `x, _ := i.(T)` 这类被忽略的类型断言结果。
+func runTypeAssertionCheck(pass *analysis.Pass, insp *inspector.Inspector) {
+	if !checkTypeAssertions {
+		return
+	}
+	insp.Preorder([]ast.Node{(*ast.AssignStmt)(nil)}, func(node ast.Node) {
+		assignStmt := node.(*ast.AssignStmt)
+		if file := pass.Fset.File(assignStmt.Pos()); file != nil && isExcludedFile(file.Name()) {
+			return
+		}
+		if len(assignStmt.Lhs) != 2 || len(assignStmt.Rhs) != 1 {
+			return
+		}
+		if _, ok := assignStmt.Rhs[0].(*ast.TypeAssertExpr); !ok {
+			return
+		}
+		okIdent, ok := assignStmt.Lhs[1].(*ast.Ident)
+		if !ok || okIdent.Name != "_" {
+			return
+		}
+		pass.Reportf(okIdent.Pos(), "type assertion result (ok) is ignored")
+	})
+}
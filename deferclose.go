@@ -0,0 +1,162 @@
+package errcheckif
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/printer"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+// runDeferBeforeCheckCheck 是 P5：检测常见的 "先 defer Close，后检查 error" 陷阱。
+// 典型场景是 `resp, err := http.Get(url)` 之后紧跟 `defer resp.Body.Close()`，
+// 而此时 err 还未被检查，一旦 err != nil，resp 可能是 nil，Close 调用就会 panic。
+func runDeferBeforeCheckCheck(pass *analysis.Pass, insp *inspector.Inspector) {
+	insp.Preorder([]ast.Node{(*ast.BlockStmt)(nil)}, func(node ast.Node) {
+		block := node.(*ast.BlockStmt)
+		if file := pass.Fset.File(block.Pos()); file != nil && isExcludedFile(file.Name()) {
+			return
+		}
+
+		for i, stmt := range block.List {
+			resultIdent, errIdent, ok := resultErrAssignment(pass, stmt)
+			if !ok {
+				continue
+			}
+			assignStmt := stmt.(*ast.AssignStmt)
+
+			for j := i + 1; j < len(block.List); j++ {
+				next := block.List[j]
+				// 只有当条件看起来像在检查 err，并且该分支确实会终止
+				// （return/panic）时，才能认为后面的 defer 是安全的——
+				// 一个空的 `if err != nil {}` 语法上能匹配 checkCondition，
+				// 但并没有真的处理 err，resp 仍然可能是 nil。
+				if ifStmt, ok := next.(*ast.IfStmt); ok && checkCondition(pass, ifStmt.Cond, errIdent) {
+					if ifBodyTerminates(ifStmt.Body) {
+						break
+					}
+					continue
+				}
+
+				deferStmt, ok := next.(*ast.DeferStmt)
+				if !ok {
+					continue
+				}
+				if isCloseCallOnIdent(pass, deferStmt.Call, resultIdent) {
+					reportDeferBeforeCheck(pass, assignStmt, deferStmt, resultIdent, errIdent)
+				}
+				break
+			}
+		}
+	})
+}
+
+// ifBodyTerminates 判断一个 if 分支的函数体是否一定会终止当前函数
+// （以 return 或 panic(...) 结尾），而不是仅仅语法上「像」一次错误检查。
+func ifBodyTerminates(body *ast.BlockStmt) bool {
+	if len(body.List) == 0 {
+		return false
+	}
+	switch last := body.List[len(body.List)-1].(type) {
+	case *ast.ReturnStmt:
+		return true
+	case *ast.ExprStmt:
+		call, ok := last.X.(*ast.CallExpr)
+		if !ok {
+			return false
+		}
+		ident, ok := call.Fun.(*ast.Ident)
+		return ok && ident.Name == "panic"
+	}
+	return false
+}
+
+// resultErrAssignment 识别形如 `resp, err := call()` 的赋值，
+// 其中 call() 的第一个返回值是指针/接口类型（如 *http.Response、*os.File），第二个是 error。
+func resultErrAssignment(pass *analysis.Pass, stmt ast.Stmt) (resultIdent, errIdent *ast.Ident, ok bool) {
+	assignStmt, isAssign := stmt.(*ast.AssignStmt)
+	if !isAssign || len(assignStmt.Rhs) != 1 || len(assignStmt.Lhs) != 2 {
+		return nil, nil, false
+	}
+	callExpr, isCall := assignStmt.Rhs[0].(*ast.CallExpr)
+	if !isCall {
+		return nil, nil, false
+	}
+	sig, isSig := pass.TypesInfo.TypeOf(callExpr.Fun).(*types.Signature)
+	if !isSig || sig.Results().Len() != 2 {
+		return nil, nil, false
+	}
+	if !implementsError(sig.Results().At(1).Type()) {
+		return nil, nil, false
+	}
+	if !isPointerOrInterfaceType(sig.Results().At(0).Type()) {
+		return nil, nil, false
+	}
+
+	resIdent, ok1 := assignStmt.Lhs[0].(*ast.Ident)
+	errIdentNode, ok2 := assignStmt.Lhs[1].(*ast.Ident)
+	if !ok1 || !ok2 || resIdent.Name == "_" || errIdentNode.Name == "_" {
+		return nil, nil, false
+	}
+	return resIdent, errIdentNode, true
+}
+
+// isPointerOrInterfaceType 判断一个类型的底层是否为指针或接口，
+// 这是 *http.Response、*os.File 等「需要手动 Close」的值的共同形态。
+func isPointerOrInterfaceType(t types.Type) bool {
+	switch t.Underlying().(type) {
+	case *types.Pointer, *types.Interface:
+		return true
+	}
+	return false
+}
+
+// isCloseCallOnIdent 判断一次 defer 调用是否是 `ident.Close()` 或 `ident.Body.Close()`。
+func isCloseCallOnIdent(pass *analysis.Pass, call *ast.CallExpr, ident *ast.Ident) bool {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "Close" {
+		return false
+	}
+	switch x := sel.X.(type) {
+	case *ast.Ident:
+		return isIdent(pass, x, ident)
+	case *ast.SelectorExpr:
+		if x.Sel.Name != "Body" {
+			return false
+		}
+		xi, ok := x.X.(*ast.Ident)
+		return ok && isIdent(pass, xi, ident)
+	}
+	return false
+}
+
+func reportDeferBeforeCheck(pass *analysis.Pass, assignStmt *ast.AssignStmt, deferStmt *ast.DeferStmt, resultIdent, errIdent *ast.Ident) {
+	deferText := renderNode(pass, deferStmt)
+	checkText := buildErrCheckFix(pass, assignStmt.Pos(), errIdent.Name)
+
+	pass.Report(analysis.Diagnostic{
+		Pos: deferStmt.Pos(),
+		Message: fmt.Sprintf(
+			"%s used before error check: defer runs before '%s' is checked and may panic on a nil %s",
+			resultIdent.Name, errIdent.Name, resultIdent.Name),
+		SuggestedFixes: []analysis.SuggestedFix{{
+			Message: "move the defer after the error check",
+			TextEdits: []analysis.TextEdit{
+				{Pos: deferStmt.Pos(), End: deferStmt.End(), NewText: []byte("")},
+				{Pos: assignStmt.End(), End: assignStmt.End(), NewText: []byte(checkText + "\n" + deferText)},
+			},
+		}},
+	})
+}
+
+// renderNode 将一个 AST 节点重新格式化为源码文本，用于在 suggested fix 中原样保留原调用。
+func renderNode(pass *analysis.Pass, node ast.Node) string {
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, pass.Fset, node); err != nil {
+		return ""
+	}
+	return buf.String()
+}
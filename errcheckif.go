@@ -1,12 +1,15 @@
 package errcheckif
 
 import (
+	"fmt"
 	"go/ast"
 	"go/token"
 	"go/types"
+	"strconv"
 	"strings"
 
 	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/buildssa"
 	"golang.org/x/tools/go/analysis/passes/inspect"
 	"golang.org/x/tools/go/ast/astutil"
 	"golang.org/x/tools/go/ast/inspector"
@@ -20,9 +23,10 @@ that error is checked in a subsequent if statement, returned directly, or used i
 It includes special handling for errors assigned within if-else blocks.`
 
 var Analyzer = &analysis.Analyzer{
-	Name:     "errcheckif", // linter 的唯一名称，用于配置文件和命令行
-	Doc:      doc,
-	Requires: []*analysis.Analyzer{inspect.Analyzer}, // 声明我们的 linter 依赖于哪些其他的分析器
+	Name: "errcheckif", // linter 的唯一名称，用于配置文件和命令行
+	Doc:  doc,
+	// 声明我们的 linter 依赖于哪些其他的分析器；buildssa 仅在 -ssa 开启时被实际使用
+	Requires: []*analysis.Analyzer{inspect.Analyzer, buildssa.Analyzer},
 	Run:      run,
 }
 
@@ -83,9 +87,20 @@ func run(pass *analysis.Pass) (interface{}, error) {
 			}
 
 			if !isHandled {
-				pass.Reportf(ifStmt.Pos(),
-					"error variable '%s' assigned in if-else block is not checked",
-					errIdent.Name)
+				pass.Report(analysis.Diagnostic{
+					Pos: ifStmt.Pos(),
+					Message: fmt.Sprintf(
+						"error variable '%s' assigned in if-else block is not checked",
+						errIdent.Name),
+					SuggestedFixes: []analysis.SuggestedFix{{
+						Message: fmt.Sprintf("check '%s' right after the if-else block", errIdent.Name),
+						TextEdits: []analysis.TextEdit{{
+							Pos:     ifStmt.End(),
+							End:     ifStmt.End(),
+							NewText: []byte(buildErrCheckFix(pass, ifStmt.Pos(), errIdent.Name)),
+						}},
+					}},
+				})
 			}
 		}
 	})
@@ -93,8 +108,8 @@ func run(pass *analysis.Pass) (interface{}, error) {
 	// --- P2: errcheckif linter ---
 	// 遍历 AST 中的 nodeFilter 的指定节点
 	inspector.Preorder([]ast.Node{(*ast.AssignStmt)(nil)}, func(node ast.Node) {
-		// 跳过测试文件的检测
-		if file := pass.Fset.File(node.Pos()); file != nil && strings.HasSuffix(file.Name(), "_test.go") {
+		// 跳过测试文件及 -exclude-files 匹配的文件
+		if file := pass.Fset.File(node.Pos()); file != nil && isExcludedFile(file.Name()) {
 			return
 		}
 
@@ -108,6 +123,11 @@ func run(pass *analysis.Pass) (interface{}, error) {
 			return
 		}
 
+		// -exclude-functions 中列出的函数不检查其返回的 error
+		if isExcludedFunctionCall(pass, callExpr) {
+			return
+		}
+
 		// 获取函数调用的类型签名
 		sig, ok := pass.TypesInfo.TypeOf(callExpr.Fun).(*types.Signature)
 		if !ok {
@@ -134,9 +154,15 @@ func run(pass *analysis.Pass) (interface{}, error) {
 				continue
 			}
 
-			// 错误被 `_` 忽略了，直接报错
+			// 错误被 `_` 忽略了，直接报错（可通过 -check-blank=false 关闭）
 			if ident.Name == "_" {
-				pass.Reportf(ident.Pos(), "error returned from function call is ignored")
+				if checkBlank {
+					pass.Report(analysis.Diagnostic{
+						Pos:            ident.Pos(),
+						Message:        "error returned from function call is ignored",
+						SuggestedFixes: buildBlankIgnoreFix(pass, assignStmt, i),
+					})
+				}
 			} else {
 				// 错误被赋给了一个具名变量，启动完整的处理检查逻辑
 				errIdent := ident
@@ -166,11 +192,35 @@ func run(pass *analysis.Pass) (interface{}, error) {
 				}
 
 				if !isHandledInSubsequentStatement(pass, errIdent, path) {
-					pass.Reportf(errIdent.Pos(), "error '%s' is not checked or returned", errIdent.Name)
+					pass.Report(analysis.Diagnostic{
+						Pos:     errIdent.Pos(),
+						Message: fmt.Sprintf("error '%s' is not checked or returned", errIdent.Name),
+						SuggestedFixes: []analysis.SuggestedFix{{
+							Message: fmt.Sprintf("check '%s' right after the assignment", errIdent.Name),
+							TextEdits: []analysis.TextEdit{{
+								Pos:     assignStmt.End(),
+								End:     assignStmt.End(),
+								NewText: []byte(buildErrCheckFix(pass, assignStmt.Pos(), errIdent.Name)),
+							}},
+						}},
+					})
 				}
 			}
 		}
 	})
+
+	// --- P3: SSA 跨函数错误传播检查（由 -ssa 开关控制）---
+	runSSAChecks(pass)
+
+	// --- P4: 类型断言结果检查（由 -check-type-assertions 开关控制）---
+	runTypeAssertionCheck(pass, inspector)
+
+	// --- P5: 检测 defer Close 先于 error 检查执行的陷阱 ---
+	runDeferBeforeCheckCheck(pass, inspector)
+
+	// --- P6: 检测 goroutine 赋值的 error 在缺少同步原语时就被检查 ---
+	runGoroutineSyncCheck(pass, inspector)
+
 	return nil, nil
 }
 
@@ -289,6 +339,17 @@ func isHandledInSubsequentStatement(pass *analysis.Pass, errIdent *ast.Ident, pa
 						return false
 					}
 				}
+				// 这个语句列表里没有找到处理。如果它恰好是一个函数字面量的函数体
+				// （比如 `go func(){...}()` 的函数体），再检查该字面量内部是否存在
+				// 一个 `defer func(){...}()` 处理了 errIdent —— defer 的执行时机是
+				// 函数返回前，不要求它出现在赋值语句之后。
+				if i+1 < len(path) {
+					if funcLit, ok := path[i+1].(*ast.FuncLit); ok && funcLit.Body == path[i] {
+						if isHandledInDeferredClosure(pass, funcLit, errIdent) {
+							return true
+						}
+					}
+				}
 				return false
 			}
 		}
@@ -296,6 +357,45 @@ func isHandledInSubsequentStatement(pass *analysis.Pass, errIdent *ast.Ident, pa
 	return false
 }
 
+// isHandledInDeferredClosure 检查一个函数字面量的函数体中，
+// 是否存在一个 `defer func(){...}()`，且其内部通过 if 语句检查了 errIdent。
+func isHandledInDeferredClosure(pass *analysis.Pass, funcLit *ast.FuncLit, errIdent *ast.Ident) bool {
+	if funcLit.Body == nil {
+		return false
+	}
+	for _, stmt := range funcLit.Body.List {
+		deferStmt, ok := stmt.(*ast.DeferStmt)
+		if !ok {
+			continue
+		}
+		deferredLit, ok := deferStmt.Call.Fun.(*ast.FuncLit)
+		if !ok {
+			continue
+		}
+		if deferredClosureChecksErr(pass, deferredLit, errIdent) {
+			return true
+		}
+	}
+	return false
+}
+
+// deferredClosureChecksErr 遍历一个被 defer 的函数字面量的函数体，
+// 查找其中是否存在满足 checkCondition 的 if 语句。
+func deferredClosureChecksErr(pass *analysis.Pass, funcLit *ast.FuncLit, errIdent *ast.Ident) bool {
+	found := false
+	ast.Inspect(funcLit.Body, func(n ast.Node) bool {
+		if found {
+			return false
+		}
+		if ifStmt, ok := n.(*ast.IfStmt); ok && checkCondition(pass, ifStmt.Cond, errIdent) {
+			found = true
+			return false
+		}
+		return true
+	})
+	return found
+}
+
 // getStmtList 从一个 AST 节点中提取出其包含的语句列表
 // 泛化处理 *ast.BlockStmt, *ast.CaseClause (用于 switch), 和 *ast.CommClause (用于 select)。
 func getStmtList(node ast.Node) []ast.Stmt {
@@ -318,6 +418,16 @@ func isStmtAValidHandler(pass *analysis.Pass, stmt ast.Node, errIdent *ast.Ident
 		return checkCondition(pass, ifStmt.Cond, errIdent)
 	}
 
+	// Case 1.1: 赋值语句中通过包装/传递消费了 errIdent，如 `err = fmt.Errorf("...: %w", err)`
+	if assignStmt, ok := stmt.(*ast.AssignStmt); ok {
+		if len(assignStmt.Rhs) == 1 {
+			if call, ok := assignStmt.Rhs[0].(*ast.CallExpr); ok && isErrorWrappingCall(pass, call, errIdent) {
+				return true
+			}
+		}
+		return false
+	}
+
 	// Case 2: 检查是否是 return 语句
 	if returnStmt, ok := stmt.(*ast.ReturnStmt); ok {
 		// 检查是否为显式返回，如 `return err`
@@ -325,6 +435,10 @@ func isStmtAValidHandler(pass *analysis.Pass, stmt ast.Node, errIdent *ast.Ident
 			if isIdent(pass, result, errIdent) {
 				return true
 			}
+			// 检查是否为包装/传递返回，如 `return fmt.Errorf("...: %w", err)` 或 `return someWrapper(err)`
+			if call, ok := result.(*ast.CallExpr); ok && isErrorWrappingCall(pass, call, errIdent) {
+				return true
+			}
 		}
 
 		// 如果是裸返回 `return;`，则检查 errIdent 是否为命名返回值
@@ -375,21 +489,92 @@ func checkCondition(pass *analysis.Pass, cond ast.Expr, errIdent *ast.Ident) boo
 		}
 	// 情况2: 函数调用, 如 errors.Is(err, ...)
 	case *ast.CallExpr:
-		// errors.Is 在 AST 中是一个选择器表达式 (*ast.SelectorExpr)，即 X.Sel
-		sel, ok := c.Fun.(*ast.SelectorExpr)
-		if !ok {
-			return false
+		// errors.Is/errors.As 在 AST 中是一个选择器表达式 (*ast.SelectorExpr)，即 X.Sel
+		if sel, ok := c.Fun.(*ast.SelectorExpr); ok {
+			// 检查 X 部分是不是 errors
+			if pkgIdent, ok := sel.X.(*ast.Ident); ok && pkgIdent.Name == "errors" {
+				// 检查 Sel 部分是不是 Is 或 As
+				if sel.Sel.Name == "Is" || sel.Sel.Name == "As" {
+					// 检查第一个参数是不是我们的 err 变量
+					if len(c.Args) > 0 && isIdent(pass, c.Args[0], errIdent) {
+						return true
+					}
+				}
+			}
 		}
-		// 检查 X 部分是不是 errors
-		if pkgIdent, ok := sel.X.(*ast.Ident); !ok || pkgIdent.Name != "errors" {
-			return false
+		// 情况2.1: 错误包装/传递，如 fmt.Errorf("...: %w", err)、errors.Join(err, ...)、errors.Unwrap(err)
+		return isErrorWrappingCall(pass, c, errIdent)
+	}
+	return false
+}
+
+// isErrorWrappingCall 判断一次函数调用是否以「包装/传递」的方式消费了 errIdent，
+// 覆盖 fmt.Errorf("...: %w", err)、errors.Join(err, ...)、errors.Unwrap(err)，
+// 以及签名形如 func(error, ...) error 的用户自定义包装函数。
+func isErrorWrappingCall(pass *analysis.Pass, call *ast.CallExpr, errIdent *ast.Ident) bool {
+	if sel, ok := call.Fun.(*ast.SelectorExpr); ok {
+		if pkgIdent, ok := sel.X.(*ast.Ident); ok && pkgIdent.Name == "errors" {
+			switch sel.Sel.Name {
+			case "Join":
+				for _, arg := range call.Args {
+					if isIdent(pass, arg, errIdent) {
+						return true
+					}
+				}
+				return false
+			case "Unwrap":
+				return len(call.Args) > 0 && isIdent(pass, call.Args[0], errIdent)
+			}
 		}
-		// 检查 Sel 部分是不是 Is 或 As
-		if sel.Sel.Name != "Is" && sel.Sel.Name != "As" {
-			return false
+		if pkgIdent, ok := sel.X.(*ast.Ident); ok && pkgIdent.Name == "fmt" && sel.Sel.Name == "Errorf" {
+			return isFmtErrorfWrap(pass, call, errIdent)
 		}
-		// 检查第一个参数是不是我们的 err 变量
-		if len(c.Args) > 0 && isIdent(pass, c.Args[0], errIdent) {
+	}
+
+	// 用户自定义的包装函数：func(error, ...) error
+	return isUserDefinedWrapperCall(pass, call, errIdent)
+}
+
+// isFmtErrorfWrap 检查 fmt.Errorf 调用的格式串是否包含 %w 动词，
+// 且 errIdent 作为对应的参数之一传入。
+func isFmtErrorfWrap(pass *analysis.Pass, call *ast.CallExpr, errIdent *ast.Ident) bool {
+	if len(call.Args) < 2 {
+		return false
+	}
+	lit, ok := call.Args[0].(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return false
+	}
+	format, err := strconv.Unquote(lit.Value)
+	if err != nil || !strings.Contains(format, "%w") {
+		return false
+	}
+	for _, arg := range call.Args[1:] {
+		if isIdent(pass, arg, errIdent) {
+			return true
+		}
+	}
+	return false
+}
+
+// isUserDefinedWrapperCall 检查调用的函数签名是否形如 func(error, ...) error，
+// 且 errIdent 被作为其中一个 error 类型的参数传入。
+func isUserDefinedWrapperCall(pass *analysis.Pass, call *ast.CallExpr, errIdent *ast.Ident) bool {
+	sig, ok := pass.TypesInfo.TypeOf(call.Fun).(*types.Signature)
+	if !ok {
+		return false
+	}
+	// 返回值必须是单一的 error
+	results := sig.Results()
+	if results.Len() != 1 || !types.Implements(results.At(0).Type(), errorInterface) {
+		return false
+	}
+	params := sig.Params()
+	for i := 0; i < params.Len() && i < len(call.Args); i++ {
+		if !types.Implements(params.At(i).Type(), errorInterface) {
+			continue
+		}
+		if isIdent(pass, call.Args[i], errIdent) {
 			return true
 		}
 	}
@@ -437,6 +622,160 @@ func isNil(pass *analysis.Pass, expr ast.Expr) bool {
 	return ok && pass.TypesInfo.ObjectOf(ident) == types.Universe.Lookup("nil")
 }
 
+// ==========================  suggested fix function  =====================================
+
+// buildErrCheckFix 在插入点构造一段规范的错误检查代码。
+// 如果插入点所在的函数有 error 返回值，生成 `if <name> != nil { return ... }`；
+// 否则生成 `if <name> != nil { panic(<name>) }`。
+func buildErrCheckFix(pass *analysis.Pass, pos token.Pos, errName string) string {
+	panicFix := fmt.Sprintf("\nif %s != nil {\n\tpanic(%s)\n}", errName, errName)
+
+	file := fileAtPos(pass, pos)
+	if file == nil {
+		return panicFix
+	}
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+
+	var funcType *ast.FuncType
+	for _, n := range path {
+		switch fn := n.(type) {
+		case *ast.FuncDecl:
+			funcType = fn.Type
+		case *ast.FuncLit:
+			funcType = fn.Type
+		}
+		if funcType != nil {
+			break
+		}
+	}
+	if funcType == nil || funcType.Results == nil {
+		return panicFix
+	}
+
+	var zeros []string
+	hasErrResult := false
+	for _, field := range funcType.Results.List {
+		n := len(field.Names)
+		if n == 0 {
+			n = 1
+		}
+		t := pass.TypesInfo.TypeOf(field.Type)
+		for k := 0; k < n; k++ {
+			if t != nil && types.Implements(t, errorInterface) {
+				hasErrResult = true
+				continue
+			}
+			zeros = append(zeros, zeroValueExpr(pass, t))
+		}
+	}
+	if !hasErrResult {
+		return panicFix
+	}
+
+	returnArgs := append(zeros, errName)
+	return fmt.Sprintf("\nif %s != nil {\n\treturn %s\n}", errName, strings.Join(returnArgs, ", "))
+}
+
+// zeroValueExpr 返回给定类型的零值字面量表示，用于拼接 `return` 语句。
+func zeroValueExpr(pass *analysis.Pass, t types.Type) string {
+	if t == nil {
+		return "nil"
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsString != 0:
+			return `""`
+		case u.Info()&types.IsBoolean != 0:
+			return "false"
+		case u.Info()&types.IsNumeric != 0:
+			return "0"
+		}
+	case *types.Pointer, *types.Slice, *types.Map, *types.Chan, *types.Interface, *types.Signature:
+		return "nil"
+	}
+	return types.TypeString(t, func(p *types.Package) string {
+		if p == pass.Pkg {
+			return ""
+		}
+		return p.Name()
+	}) + "{}"
+}
+
+// canUseShortVarDecl 判断把 assignStmt 的 `=` 换成 `:=` 是否安全。
+// 只有当其余非 `_` 的左侧标识符都在 assignStmt 所在的同一词法块中声明时，
+// 才是安全的——否则 `:=` 会在当前块里新建一个遮蔽外层同名变量的新变量，
+// 外层变量就再也不会被这条语句更新了。
+func canUseShortVarDecl(pass *analysis.Pass, assignStmt *ast.AssignStmt) bool {
+	path, _ := astutil.PathEnclosingInterval(findFile(pass, assignStmt), assignStmt.Pos(), assignStmt.End())
+
+	var scope *types.Scope
+	for _, n := range path {
+		if getStmtList(n) != nil {
+			scope = pass.TypesInfo.Scopes[n]
+			break
+		}
+	}
+	if scope == nil {
+		return false
+	}
+
+	for _, lhs := range assignStmt.Lhs {
+		ident, ok := lhs.(*ast.Ident)
+		if !ok || ident.Name == "_" {
+			continue
+		}
+		obj := pass.TypesInfo.ObjectOf(ident)
+		if obj == nil || obj.Parent() != scope {
+			return false
+		}
+	}
+	return true
+}
+
+// buildBlankIgnoreFix 为 `_, _ = fn()` 这类被忽略的错误构造修复：
+// 将对应位置的 `_` 改写为 `err`，并在语句之后插入错误检查。
+func buildBlankIgnoreFix(pass *analysis.Pass, assignStmt *ast.AssignStmt, errIdx int) []analysis.SuggestedFix {
+	blankIdent := assignStmt.Lhs[errIdx]
+	edits := []analysis.TextEdit{
+		{Pos: blankIdent.Pos(), End: blankIdent.End(), NewText: []byte("err")},
+	}
+	if assignStmt.Tok == token.ASSIGN {
+		if canUseShortVarDecl(pass, assignStmt) {
+			edits = append(edits, analysis.TextEdit{
+				Pos:     assignStmt.TokPos,
+				End:     assignStmt.TokPos + token.Pos(len("=")),
+				NewText: []byte(":="),
+			})
+		} else {
+			// 其余左侧变量声明在外层作用域；把 `=` 换成 `:=` 只会在当前块里
+			// 新建一个同名的遮蔽变量，外层变量永远不会被赋值，静默改变程序行为。
+			// 这里改为插入一条独立的 `var err error`，继续使用 `=`。
+			edits = append(edits, analysis.TextEdit{
+				Pos:     assignStmt.Pos(),
+				End:     assignStmt.Pos(),
+				NewText: []byte("var err error\n\t"),
+			})
+		}
+	}
+	edits = append(edits, analysis.TextEdit{
+		Pos:     assignStmt.End(),
+		End:     assignStmt.End(),
+		NewText: []byte(buildErrCheckFix(pass, assignStmt.Pos(), "err")),
+	})
+	return []analysis.SuggestedFix{{Message: "check the ignored error", TextEdits: edits}}
+}
+
+// fileAtPos 根据一个位置找到它所属的 *ast.File
+func fileAtPos(pass *analysis.Pass, pos token.Pos) *ast.File {
+	for _, file := range pass.Files {
+		if file.Pos() <= pos && pos <= file.End() {
+			return file
+		}
+	}
+	return nil
+}
+
 // findFile 根据一个节点的位置找到它所属的 *ast.File
 func findFile(pass *analysis.Pass, node ast.Node) *ast.File {
 	for _, file := range pass.Files {
@@ -0,0 +1,116 @@
+package errcheckif
+
+import (
+	"go/ast"
+	"go/token"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+// runGoroutineSyncCheck 是 P6：检测 `go func(){ err = ... }()` 之后，
+// 外层在没有任何同步原语（sync.WaitGroup.Wait、channel 接收）的情况下
+// 就去检查被 goroutine 赋值的 error —— 这是一个数据竞争形状的 bug：
+// 读取发生时，goroutine 可能根本还没有完成赋值。
+//
+// 当 -ssa 开启时，ssa_check.go 里的 checkGoroutineSynchronization 覆盖了同样的场景，
+// 而且是基于真实的 SSA 数据流（不依赖 AST 的相邻语句启发式），更准确，因此这里直接让路，
+// 避免同一个问题被 AST 和 SSA 两个 pass 各报一遍。
+func runGoroutineSyncCheck(pass *analysis.Pass, insp *inspector.Inspector) {
+	if ssaEnabled {
+		return
+	}
+	insp.Preorder([]ast.Node{(*ast.BlockStmt)(nil)}, func(node ast.Node) {
+		block := node.(*ast.BlockStmt)
+		if file := pass.Fset.File(block.Pos()); file != nil && isExcludedFile(file.Name()) {
+			return
+		}
+
+		for i, stmt := range block.List {
+			goStmt, ok := stmt.(*ast.GoStmt)
+			if !ok {
+				continue
+			}
+			funcLit, ok := goStmt.Call.Fun.(*ast.FuncLit)
+			if !ok {
+				continue
+			}
+
+			capturedErrs := capturedErrorAssignments(pass, funcLit)
+			if len(capturedErrs) == 0 {
+				continue
+			}
+
+			for j := i + 1; j < len(block.List); j++ {
+				next := block.List[j]
+				if hasSynchronizationStmt(next) {
+					// 后续语句里已经出现了同步原语，goroutine 之后的读取是安全的
+					break
+				}
+				for _, errIdent := range capturedErrs {
+					if ifStmt, ok := next.(*ast.IfStmt); ok && checkCondition(pass, ifStmt.Cond, errIdent) {
+						pass.Reportf(ifStmt.Pos(),
+							"error '%s' checked right after 'go func()' without synchronization "+
+								"(missing sync.WaitGroup.Wait or a channel receive); this is a data race",
+							errIdent.Name)
+					}
+				}
+			}
+		}
+	})
+}
+
+// capturedErrorAssignments 收集一个函数字面量体内，所有通过 `=`（而非 `:=`）
+// 赋值的 error 类型标识符 —— 这种写法意味着该变量是从外层作用域捕获的。
+func capturedErrorAssignments(pass *analysis.Pass, funcLit *ast.FuncLit) []*ast.Ident {
+	var idents []*ast.Ident
+	ast.Inspect(funcLit.Body, func(n ast.Node) bool {
+		assignStmt, ok := n.(*ast.AssignStmt)
+		if !ok || assignStmt.Tok != token.ASSIGN {
+			return true
+		}
+		for _, lhs := range assignStmt.Lhs {
+			ident, ok := lhs.(*ast.Ident)
+			if !ok || ident.Name == "_" {
+				continue
+			}
+			// 在字面量内部声明的变量不是捕获来的，跳过
+			if obj := pass.TypesInfo.ObjectOf(ident); obj == nil || (obj.Pos() >= funcLit.Pos() && obj.Pos() < funcLit.End()) {
+				continue
+			}
+			if implementsError(pass.TypesInfo.TypeOf(ident)) {
+				idents = append(idents, ident)
+			}
+		}
+		return true
+	})
+	return idents
+}
+
+// hasSynchronizationStmt 粗略检测一条语句中是否出现了同步原语：
+// channel 接收（`<-ch`、`select`）或 (*sync.WaitGroup).Wait() 调用。
+func hasSynchronizationStmt(stmt ast.Stmt) bool {
+	found := false
+	ast.Inspect(stmt, func(n ast.Node) bool {
+		if found {
+			return false
+		}
+		switch v := n.(type) {
+		case *ast.UnaryExpr:
+			if v.Op == token.ARROW {
+				found = true
+				return false
+			}
+		case *ast.SelectStmt:
+			found = true
+			return false
+		case *ast.CallExpr:
+			if sel, ok := v.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "Wait" {
+				found = true
+				return false
+			}
+		}
+		return true
+	})
+	return found
+}
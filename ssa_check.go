@@ -0,0 +1,429 @@
+package errcheckif
+
+import (
+	"go/constant"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/buildssa"
+	"golang.org/x/tools/go/ssa"
+)
+
+// ssaEnabled 控制是否启用基于 SSA 的跨函数/跨 goroutine 错误传播检查，
+// 通过 `-ssa` 命令行开关开启。AST pass (P1/P2) 足够覆盖大部分场景，
+// SSA pass 用来补齐 AST 难以表达的跨函数传播与被遮蔽（shadow）的 err 场景。
+var ssaEnabled bool
+
+func init() {
+	Analyzer.Flags.BoolVar(&ssaEnabled, "ssa", false,
+		"enable additional SSA-based interprocedural error-propagation checks (experimental)")
+}
+
+// runSSAChecks 是 P3 的入口：遍历包内每个函数的 SSA 形式，
+// 寻找没有任何下游使用能够到达 return / nil 比较 / errors.Is|As|Unwrap / %w 包装 的 error 值。
+func runSSAChecks(pass *analysis.Pass) {
+	if !ssaEnabled {
+		return
+	}
+
+	ssaInfo, ok := pass.ResultOf[buildssa.Analyzer].(*buildssa.SSA)
+	if !ok || ssaInfo == nil {
+		return
+	}
+
+	for _, fn := range ssaInfo.SrcFuncs {
+		checkFuncErrorPropagation(pass, fn)
+		checkGoroutineSynchronization(pass, fn)
+	}
+}
+
+// checkFuncErrorPropagation 检查函数内所有由调用/多返回值抽取产生的 error 值，
+// 是否存在一条到达有效处理形式的使用路径。
+func checkFuncErrorPropagation(pass *analysis.Pass, fn *ssa.Function) {
+	for _, block := range fn.Blocks {
+		for _, instr := range block.Instrs {
+			val, ok := instr.(ssa.Value)
+			if !ok {
+				continue
+			}
+			// 只关心由函数调用或多返回值抽取产生的 error，忽略字面量/参数等
+			switch instr.(type) {
+			case *ssa.Call, *ssa.Extract:
+			default:
+				continue
+			}
+			if !implementsError(val.Type()) {
+				continue
+			}
+			pos := val.Pos()
+			if extract, ok := instr.(*ssa.Extract); ok && pos == token.NoPos {
+				// emitExtract 从不调用 setPos，所以 `result, err := f()` 里
+				// err 对应的 *ssa.Extract 永远是 token.NoPos —— 这正是
+				// `(T, error)` 这种最常见返回形态。退回到产生该元组的
+				// 调用（Extract.Tuple，通常是 *ssa.Call）的位置。
+				pos = extract.Tuple.Pos()
+			}
+			if pos == token.NoPos {
+				continue
+			}
+			if isErrorValueHandled(val) {
+				continue
+			}
+			pass.Reportf(pos,
+				"error value in function %s has no reachable check, return, or wrap (ssa)", fn.Name())
+		}
+	}
+}
+
+// isErrorValueHandled 检查一个 SSA error 值的所有使用者（referrers）中，
+// 是否至少有一个满足「已处理」的形式。
+func isErrorValueHandled(v ssa.Value) bool {
+	return isErrorValueHandledRec(v, make(map[ssa.Value]bool))
+}
+
+// isErrorValueHandledRec 是 isErrorValueHandled 的递归实现。当一个 error 值
+// 被合并进一个 *ssa.Phi（典型的 if/else 汇合，比如 `err = a() / err = b()` 之后
+// 共用同一个 err 变量）时，需要递归检查这个 phi 节点本身是否被处理，
+// 而不是在这里就直接判定为未处理。visited 防止循环 phi（例如 for 循环）无限递归。
+func isErrorValueHandledRec(v ssa.Value, visited map[ssa.Value]bool) bool {
+	if visited[v] {
+		return false
+	}
+	visited[v] = true
+
+	refs := v.Referrers()
+	if refs == nil {
+		return false
+	}
+	for _, ref := range *refs {
+		switch r := ref.(type) {
+		case *ssa.Return:
+			for _, res := range r.Results {
+				if res == v {
+					return true
+				}
+			}
+		case *ssa.BinOp:
+			if r.Op == token.EQL || r.Op == token.NEQ {
+				if isNilConst(r.X) || isNilConst(r.Y) {
+					return true
+				}
+			}
+		case *ssa.Call:
+			if isErrorHandlingCall(r, v) {
+				return true
+			}
+		case *ssa.Phi:
+			if isErrorValueHandledRec(r, visited) {
+				return true
+			}
+		case *ssa.ChangeInterface:
+			// err 被装箱成一个更宽的接口类型——最典型的是 fmt.Errorf 的
+			// `...interface{}` 变长参数在存入前先做的 `change interface any <- error`。
+			// 装箱本身不算处理，但需要顺着装箱后的值继续找下去。
+			if isVariadicBoxHandled(r, v) {
+				return true
+			}
+		case *ssa.MakeInterface:
+			if isVariadicBoxHandled(r, v) {
+				return true
+			}
+		case *ssa.Store:
+			// v 未经装箱，直接被存入了变长参数数组的某个元素——
+			// 例如 `errors.Join(err, ...)` 里 ...error 形参不需要接口装箱，
+			// v 的直接使用者就是这里的 Store。
+			if r.Val == v && isVariadicArrayElementHandled(r, v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isVariadicBoxHandled 检查一个装箱值（*ssa.ChangeInterface 或 *ssa.MakeInterface）
+// 是否被存入了某个变长参数数组（`new [N]T` + IndexAddr + Store 的模式），
+// 并且该数组最终被切片后传给了一次会处理 origErr 的调用
+// （fmt.Errorf 的 `...interface{}` 形参会走到这条装箱路径；具体 error 类型的值
+// 传给 errors.Join 的 `...error` 形参时，同样先经 MakeInterface 装箱再存入数组）。
+func isVariadicBoxHandled(box ssa.Value, origErr ssa.Value) bool {
+	refs := box.Referrers()
+	if refs == nil {
+		return false
+	}
+	for _, ref := range *refs {
+		store, ok := ref.(*ssa.Store)
+		if !ok || store.Val != box {
+			continue
+		}
+		if isVariadicArrayElementHandled(store, origErr) {
+			return true
+		}
+	}
+	return false
+}
+
+// isVariadicArrayElementHandled 从一次把值存入变长参数数组某个元素的 Store 出发，
+// 顺着 IndexAddr -> Alloc -> Slice -> Call 往下找，判断这个数组最终是否被
+// 切片后传给了一次会处理 origErr 的调用。
+func isVariadicArrayElementHandled(store *ssa.Store, origErr ssa.Value) bool {
+	idxAddr, ok := store.Addr.(*ssa.IndexAddr)
+	if !ok {
+		return false
+	}
+	alloc, ok := idxAddr.X.(*ssa.Alloc)
+	if !ok {
+		return false
+	}
+	allocRefs := alloc.Referrers()
+	if allocRefs == nil {
+		return false
+	}
+	for _, aref := range *allocRefs {
+		sl, ok := aref.(*ssa.Slice)
+		if !ok {
+			continue
+		}
+		slRefs := sl.Referrers()
+		if slRefs == nil {
+			continue
+		}
+		for _, sref := range *slRefs {
+			if call, ok := sref.(*ssa.Call); ok && isErrorHandlingCall(call, origErr) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isErrorHandlingCall 判断一次调用是否以 errors.Is/As/Unwrap/Join、
+// fmt.Errorf("...: %w", ...) 或签名为 func(error, ...) error 的自定义包装函数处理了 v。
+func isErrorHandlingCall(call *ssa.Call, v ssa.Value) bool {
+	common := call.Common()
+	callee := common.StaticCallee()
+	if callee == nil {
+		return false
+	}
+
+	if pkg := callee.Pkg; pkg != nil && pkg.Pkg != nil {
+		switch pkg.Pkg.Path() {
+		case "errors":
+			switch callee.Name() {
+			case "Is", "As", "Unwrap":
+				return argsContain(common.Args, v)
+			case "Join":
+				// errors.Join(errs ...error) 是变长参数，SSA 会把调用方的实参
+				// 打包成一个 `new [N]error` 数组 + 一系列 IndexAddr/Store，再取 slice
+				// 传给 common.Args；这里把该数组里实际存入的值也纳入比对范围。
+				return argsContainVariadic(common.Args, v)
+			}
+		case "fmt":
+			if callee.Name() == "Errorf" {
+				return isFmtErrorfWrapSSA(common.Args, v)
+			}
+		}
+	}
+
+	sig := callee.Signature
+	if sig.Results().Len() != 1 || !implementsError(sig.Results().At(0).Type()) {
+		return false
+	}
+	for i, arg := range common.Args {
+		if arg != v || i >= sig.Params().Len() {
+			continue
+		}
+		if implementsError(sig.Params().At(i).Type()) {
+			return true
+		}
+	}
+	return false
+}
+
+// isFmtErrorfWrapSSA 检查 fmt.Errorf 调用的第一个参数（格式串常量）是否含有 %w，
+// 且 v 作为后续参数之一传入。
+func isFmtErrorfWrapSSA(args []ssa.Value, v ssa.Value) bool {
+	if len(args) < 2 {
+		return false
+	}
+	c, ok := args[0].(*ssa.Const)
+	if !ok || c.Value == nil || c.Value.Kind() != constant.String {
+		return false
+	}
+	if !strings.Contains(constant.StringVal(c.Value), "%w") {
+		return false
+	}
+	return argsContainVariadic(args[1:], v)
+}
+
+func argsContain(args []ssa.Value, v ssa.Value) bool {
+	for _, a := range args {
+		if a == v {
+			return true
+		}
+	}
+	return false
+}
+
+// argsContainVariadic 与 argsContain 类似，但额外展开每个实参：
+// 如果它是一个由 `new [N]T` + IndexAddr/Store 构造出的变长参数 slice，
+// 则同时比对其中实际存入的各个元素值。
+func argsContainVariadic(args []ssa.Value, v ssa.Value) bool {
+	for _, a := range args {
+		if a == v || unwrapInterfaceBox(a) == v {
+			return true
+		}
+		if variadicSliceContains(a, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// unwrapInterfaceBox 剥离一层 *ssa.ChangeInterface 或 *ssa.MakeInterface 装箱。
+// `fmt.Errorf` 的变长参数类型是 `...interface{}`，而 err 是具体的 error 接口值，
+// SSA 构建时会先插入一次 `change interface any <- error(err)`（或 MakeInterface）
+// 把它装箱成 any，再存入变长参数数组；和 `errors.Join`/`errors.Is` 等
+// `...error`/`error` 形参不同，这里如果不剥箱直接比较永远不会等于原始的 v。
+func unwrapInterfaceBox(val ssa.Value) ssa.Value {
+	for {
+		switch v := val.(type) {
+		case *ssa.ChangeInterface:
+			val = v.X
+		case *ssa.MakeInterface:
+			val = v.X
+		default:
+			return val
+		}
+	}
+}
+
+// variadicSliceContains 检查 arg（期望是一个 *ssa.Slice）底层数组中，
+// 是否存在一次把 v 存入某个元素的 Store。
+func variadicSliceContains(arg ssa.Value, v ssa.Value) bool {
+	sl, ok := arg.(*ssa.Slice)
+	if !ok {
+		return false
+	}
+	alloc, ok := sl.X.(*ssa.Alloc)
+	if !ok {
+		return false
+	}
+	allocRefs := alloc.Referrers()
+	if allocRefs == nil {
+		return false
+	}
+	for _, ref := range *allocRefs {
+		idxAddr, ok := ref.(*ssa.IndexAddr)
+		if !ok {
+			continue
+		}
+		idxRefs := idxAddr.Referrers()
+		if idxRefs == nil {
+			continue
+		}
+		for _, idxRef := range *idxRefs {
+			if store, ok := idxRef.(*ssa.Store); ok && unwrapInterfaceBox(store.Val) == v {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isNilConst(v ssa.Value) bool {
+	c, ok := v.(*ssa.Const)
+	return ok && c.IsNil()
+}
+
+func implementsError(t types.Type) bool {
+	return t != nil && errorInterface != nil && types.Implements(t, errorInterface)
+}
+
+// checkGoroutineSynchronization 检查 `go func(){ ... }()` 中被赋值的、
+// 由外层闭包捕获的 error 变量，在 goroutine 之后是否缺少同步原语
+// （sync.WaitGroup.Wait 或 channel 接收）就被使用。
+func checkGoroutineSynchronization(pass *analysis.Pass, fn *ssa.Function) {
+	for _, block := range fn.Blocks {
+		for _, instr := range block.Instrs {
+			goInstr, ok := instr.(*ssa.Go)
+			if !ok {
+				continue
+			}
+			closure, ok := goInstr.Call.Value.(*ssa.MakeClosure)
+			if !ok {
+				continue
+			}
+			anon, ok := closure.Fn.(*ssa.Function)
+			if !ok {
+				continue
+			}
+			for i, fv := range anon.FreeVars {
+				if !isErrorPointer(fv.Type()) {
+					continue
+				}
+				if !isWrittenInFunction(anon, fv) {
+					continue
+				}
+				if i >= len(closure.Bindings) {
+					continue
+				}
+				if !hasSynchronizationAfter(fn, goInstr) {
+					pass.Reportf(goInstr.Pos(),
+						"error assigned inside goroutine is used without synchronization (sync.WaitGroup/channel) (ssa)")
+				}
+			}
+		}
+	}
+}
+
+// isErrorPointer 判断一个类型是否是「指向 error 的指针」，
+// 这是被闭包捕获的局部变量在 SSA 中呈现的形态。
+func isErrorPointer(t types.Type) bool {
+	ptr, ok := t.Underlying().(*types.Pointer)
+	return ok && implementsError(ptr.Elem())
+}
+
+// isWrittenInFunction 检查 v（一个指针）在函数体内是否存在 Store 写入。
+func isWrittenInFunction(fn *ssa.Function, v ssa.Value) bool {
+	for _, block := range fn.Blocks {
+		for _, instr := range block.Instrs {
+			store, ok := instr.(*ssa.Store)
+			if ok && store.Addr == v {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasSynchronizationAfter 在 go 语句之后，粗略地检查同一函数内
+// 是否出现了 (*sync.WaitGroup).Wait 调用或 channel 接收操作。
+func hasSynchronizationAfter(fn *ssa.Function, after *ssa.Go) bool {
+	seenGo := false
+	for _, block := range fn.Blocks {
+		for _, instr := range block.Instrs {
+			if instr == after {
+				seenGo = true
+				continue
+			}
+			if !seenGo {
+				continue
+			}
+			switch i := instr.(type) {
+			case *ssa.UnOp:
+				if i.Op == token.ARROW {
+					return true
+				}
+			case *ssa.Call:
+				if callee := i.Call.StaticCallee(); callee != nil && callee.Name() == "Wait" {
+					return true
+				}
+			case *ssa.Select:
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"sync"
 )
 
 func mightFail() (string, error) {
@@ -34,7 +35,10 @@ func test() {
 		panic(err)
 	}
 
-	// 正确 2
+	// 正确 2（P1/P2：err 本身被 errors.Is 正确检查了）
+	// 注意：if 分支并没有 return/panic，所以 f 仍可能是 nil —— 这也是 P5
+	// 「defer 先于检查」在 d55c6a1 收紧判定后会额外报告的一处真实发现，
+	// 并非误报，这里有意保留，不去「修复」成提前返回。
 	f, err := os.Open("non-existent-file.txt")
 	if errors.Is(err, os.ErrNotExist) {
 		fmt.Println("file does not exist")
@@ -89,9 +93,9 @@ func test() {
 		}
 	}
 
-	// 未能解决的问题
-
 	// 并发
+
+	// 正确：defer 中的检查发生在函数返回前，即使它出现在赋值语句之前也算已处理
 	go func() {
 		var terr error
 		defer func() {
@@ -101,6 +105,8 @@ func test() {
 		terr = fail()
 	}()
 
+	// 错误：terr 是从外层捕获的，goroutine 里赋值后，外层没有任何同步
+	// （WaitGroup.Wait/channel 接收）就去检查它，属于数据竞争
 	var terr error
 	go func() {
 		terr = fail() // 协程赋值
@@ -121,6 +127,53 @@ func test_naked_return() (err error) {
 	return
 }
 
+// ================= 错误包装/传递 ==================
+
+func wrap(err error) error {
+	return fmt.Errorf("wrap: %w", err)
+}
+
+// 正确 10 fmt.Errorf %w 包装
+// 注意：这里故意不加 `if err != nil` 检查，而是先把包装结果存进一个变量，
+// 再返回该变量——如果删掉 isErrorWrappingCall 的识别逻辑，这一条会先于
+// test_wrap_errorf_return 暴露出来（那一条是直接在 return 里包装）。
+func test_wrap_errorf() error {
+	_, err := mightFail()
+	wrapped := fmt.Errorf("mightFail: %w", err)
+	return wrapped
+}
+
+// 正确 11 直接返回包装结果
+func test_wrap_errorf_return() error {
+	_, err := mightFail()
+	return fmt.Errorf("mightFail: %w", err)
+}
+
+// 正确 12 自定义包装函数 func(error, ...) error
+func test_wrap_custom() error {
+	_, err := mightFail()
+	return wrap(err)
+}
+
+// 正确 13 重新赋值为包装后的错误
+func test_wrap_reassign() error {
+	err := fail()
+	err = fmt.Errorf("fail: %w", err)
+	return err
+}
+
+// 正确 14 errors.Join
+func test_wrap_join() error {
+	err := fail()
+	return errors.Join(err, fail())
+}
+
+// 正确 15 errors.Unwrap
+func test_wrap_unwrap() error {
+	err := fail()
+	return errors.Unwrap(err)
+}
+
 // 错误
 func test_cross(cond bool) {
 	err := fail() // Linter 发现 err A
@@ -215,3 +268,80 @@ func ftest02(cond bool) {
 		_, err = os.Open("test.txt")
 	}
 }
+
+// ================= 可配置的排除规则与可选检查 ==================
+
+// 正确 16 -exclude-functions=fmt.Println 时，这里不会被报告
+func test_exclude_functions() {
+	_, _ = fmt.Println("hello")
+}
+
+// 错误：v 声明在外层作用域，`_` 忽略的错误自动修复不能简单地把
+// `=` 改成 `:=`，否则会在内层块里新建一个遮蔽 v 的新变量，外层 v 永远不会被更新。
+func test_blank_ignore_outer_scope_shadow() {
+	v := "initial"
+	{
+		v, _ = mightFail()
+		fmt.Println(v)
+	}
+}
+
+// 错误（默认 -check-type-assertions=false 时不报告，开启后会报告）
+func test_type_assertion_ignored(i interface{}) string {
+	s, _ := i.(string)
+	return s
+}
+
+// ================= defer Close 先于 error 检查 ==================
+
+// 错误：resp 可能为 nil，此处先 defer Close 再检查 err，一旦请求失败就会 panic
+func test_defer_before_check(url string) error {
+	resp, err := http.Get(url)
+	defer resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	fmt.Println(resp.StatusCode)
+	return nil
+}
+
+// 错误：if 分支的条件看起来像在检查 err，但函数体是空的，并不会终止，
+// 所以 resp 仍然可能是 nil，之后的 defer Close 依然会 panic
+func test_defer_before_check_empty_body(url string) error {
+	resp, err := http.Get(url)
+	if err != nil {
+	}
+	defer resp.Body.Close()
+	fmt.Println(resp.StatusCode)
+	return nil
+}
+
+// 正确：先检查 err，再 defer Close
+func test_defer_after_check(url string) error {
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	fmt.Println(resp.StatusCode)
+	return nil
+}
+
+// ================= goroutine 中的同步 ==================
+
+// 用 sync.WaitGroup.Wait 同步后再检查 goroutine 赋值的 error：
+// 不会触发新增的「缺少同步」检查（P2 对 goroutine 内赋值本身的检查仍是已知局限）
+func test_goroutine_synced() error {
+	var wg sync.WaitGroup
+	var terr error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		terr = fail()
+	}()
+	wg.Wait()
+	if terr != nil {
+		return terr
+	}
+	return nil
+}